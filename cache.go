@@ -0,0 +1,229 @@
+package main
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultCacheTTL      = 5 * time.Minute
+	defaultCacheCapacity = 256
+)
+
+// CachedChunk is one SSE "data: " payload captured from a streaming
+// response, along with how long after the previous chunk (or the start of
+// the stream) it arrived, so a replay can reproduce realistic pacing.
+type CachedChunk struct {
+	Data  []byte
+	Delay time.Duration
+}
+
+// CachedResponse is everything needed to replay a provider response without
+// calling upstream again.
+type CachedResponse struct {
+	IsStreaming bool
+	Chunks      []CachedChunk // for streaming responses
+	Body        []byte        // for non-streaming responses
+}
+
+// CacheBackend stores CachedResponses behind a pluggable backend. The
+// built-in implementation is an in-memory LRU; a Redis- or disk-backed
+// implementation can satisfy the same interface without any caller changes,
+// the same way Provider lets new upstreams plug in.
+type CacheBackend interface {
+	Get(key string) (*CachedResponse, bool)
+	Set(key string, entry *CachedResponse, ttl time.Duration)
+}
+
+// memoryLRUCache is a fixed-capacity, TTL-aware in-memory CacheBackend.
+type memoryLRUCache struct {
+	mu       sync.Mutex
+	capacity int
+	order    *list.List
+	items    map[string]*list.Element
+}
+
+type lruEntry struct {
+	key       string
+	value     *CachedResponse
+	expiresAt time.Time
+}
+
+// NewMemoryLRUCache creates an in-memory CacheBackend holding at most
+// capacity entries, evicting the least recently used one once full.
+func NewMemoryLRUCache(capacity int) *memoryLRUCache {
+	return &memoryLRUCache{
+		capacity: capacity,
+		order:    list.New(),
+		items:    make(map[string]*list.Element),
+	}
+}
+
+func (c *memoryLRUCache) Get(key string) (*CachedResponse, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+
+	entry := el.Value.(*lruEntry)
+	if time.Now().After(entry.expiresAt) {
+		c.order.Remove(el)
+		delete(c.items, key)
+		return nil, false
+	}
+
+	c.order.MoveToFront(el)
+	return entry.value, true
+}
+
+func (c *memoryLRUCache) Set(key string, value *CachedResponse, ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.items[key]; ok {
+		entry := el.Value.(*lruEntry)
+		entry.value = value
+		entry.expiresAt = time.Now().Add(ttl)
+		c.order.MoveToFront(el)
+		return
+	}
+
+	el := c.order.PushFront(&lruEntry{key: key, value: value, expiresAt: time.Now().Add(ttl)})
+	c.items[key] = el
+
+	if c.order.Len() > c.capacity {
+		oldest := c.order.Back()
+		if oldest != nil {
+			c.order.Remove(oldest)
+			delete(c.items, oldest.Value.(*lruEntry).key)
+		}
+	}
+}
+
+// cacheKeyFields is the subset of a chat completion request that determines
+// its response, marshaled to JSON to build the cache key. Struct field
+// order is fixed and encoding/json sorts map keys, so two requests that
+// differ only in how their JSON was originally formatted hash the same.
+// Stream is included because a streaming and a non-streaming request get
+// their response in different wire formats even when everything else about
+// the request matches, so they must never share a cache entry. Tenant is
+// included because tenants carry their own upstream credentials: without
+// it, one tenant's request could be served a response that was actually
+// generated (and billed) under another tenant's API key.
+type cacheKeyFields struct {
+	Tenant      string                   `json:"tenant"`
+	Model       string                   `json:"model"`
+	Messages    []map[string]interface{} `json:"messages"`
+	Stream      bool                     `json:"stream"`
+	Temperature *float64                 `json:"temperature,omitempty"`
+	Tools       []map[string]interface{} `json:"tools,omitempty"`
+}
+
+// requestCacheKey builds a stable cache key for a chat completion request
+// made by tenant (the tenant name resolved by authMiddleware, or "default"
+// in single-tenant mode).
+func requestCacheKey(tenant string, chatReq OpenAIChatCompletionRequest) string {
+	fields := cacheKeyFields{
+		Tenant:      tenant,
+		Model:       chatReq.Model,
+		Messages:    chatReq.Messages,
+		Stream:      chatReq.Stream,
+		Temperature: chatReq.Temperature,
+		Tools:       chatReq.Tools,
+	}
+	canonical, _ := json.Marshal(fields)
+	sum := sha256.Sum256(canonical)
+	return hex.EncodeToString(sum[:])
+}
+
+// wantsNoStore reports whether the client opted out of caching via
+// "Cache-Control: no-store".
+func wantsNoStore(r *http.Request) bool {
+	return strings.EqualFold(r.Header.Get("Cache-Control"), "no-store")
+}
+
+// cacheRecorder accumulates the chunks of a streaming response as they're
+// sent to the client, along with their inter-chunk arrival delays, so the
+// whole stream can be stored and replayed later.
+type cacheRecorder struct {
+	chunks   []CachedChunk
+	lastTime time.Time
+}
+
+func newCacheRecorder() *cacheRecorder {
+	return &cacheRecorder{lastTime: time.Now()}
+}
+
+// Add records one chunk, measuring its delay from the previous Add call (or
+// from recorder creation, for the first chunk).
+func (cr *cacheRecorder) Add(data []byte) {
+	now := time.Now()
+	cr.chunks = append(cr.chunks, CachedChunk{
+		Data:  append([]byte(nil), data...),
+		Delay: now.Sub(cr.lastTime),
+	})
+	cr.lastTime = now
+}
+
+// Result builds the CachedResponse to store once the stream has ended.
+func (cr *cacheRecorder) Result() *CachedResponse {
+	return &CachedResponse{IsStreaming: true, Chunks: cr.chunks}
+}
+
+// cachedUsage extracts the token usage recorded in a cached response, if
+// any, so a cache hit can still populate access logs and rate limiting.
+func cachedUsage(cached *CachedResponse) (OpenAIUsage, bool) {
+	if !cached.IsStreaming {
+		return parseUsage(cached.Body)
+	}
+	for i := len(cached.Chunks) - 1; i >= 0; i-- {
+		if usage, found := parseUsage(cached.Chunks[i].Data); found {
+			return usage, true
+		}
+	}
+	return OpenAIUsage{}, false
+}
+
+// replayCachedResponse writes a cached response straight to the client,
+// pacing streaming chunks by their originally recorded delays.
+func replayCachedResponse(w http.ResponseWriter, cached *CachedResponse) {
+	w.Header().Set("X-Cache", "HIT")
+
+	if !cached.IsStreaming {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.Write(cached.Body)
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("Transfer-Encoding", "chunked")
+	w.WriteHeader(http.StatusOK)
+
+	flusher, _ := w.(http.Flusher)
+	for _, chunk := range cached.Chunks {
+		if chunk.Delay > 0 {
+			time.Sleep(chunk.Delay)
+		}
+		w.Write([]byte("data: "))
+		w.Write(chunk.Data)
+		w.Write([]byte("\n"))
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+	w.Write([]byte("data: [DONE]\n"))
+	if flusher != nil {
+		flusher.Flush()
+	}
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// defaultShutdownTimeout bounds how long graceful shutdown waits for
+// in-flight streams to finish draining before main returns and the process
+// exits regardless.
+const defaultShutdownTimeout = 30 * time.Second
+
+// shutdownTimeoutFromEnv reads SHUTDOWN_TIMEOUT_SECONDS, falling back to
+// defaultShutdownTimeout for an unset or invalid value.
+func shutdownTimeoutFromEnv() time.Duration {
+	return time.Duration(envInt("SHUTDOWN_TIMEOUT_SECONDS", int(defaultShutdownTimeout/time.Second))) * time.Second
+}
+
+// ready reports whether the server is currently accepting new work; it's
+// flipped to 0 as soon as graceful shutdown begins, so /readyz fails before
+// the listener actually stops accepting connections.
+var ready int32
+
+func init() {
+	atomic.StoreInt32(&ready, 1)
+}
+
+// healthzHandler reports whether the process is up, regardless of whether
+// it's draining for shutdown.
+func healthzHandler(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("ok"))
+}
+
+// readyzHandler reports whether the server should still receive new
+// traffic, so a load balancer can stop routing here during shutdown drain.
+func readyzHandler(w http.ResponseWriter, r *http.Request) {
+	if atomic.LoadInt32(&ready) == 0 {
+		http.Error(w, "shutting down", http.StatusServiceUnavailable)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("ok"))
+}
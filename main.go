@@ -3,13 +3,17 @@ package main
 import (
 	"bufio"
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
 	"os"
+	"os/signal"
 	"strings"
+	"sync/atomic"
+	"syscall"
 	"time"
 
 	"github.com/joho/godotenv"
@@ -36,7 +40,8 @@ const (
 
 // Config holds the application configuration
 type Config struct {
-	OpenAIAPIKey string
+	OpenAIAPIKey    string
+	AnthropicAPIKey string
 }
 
 // OpenAIChatCompletionRequest represents the structure of an OpenAI chat completion request
@@ -45,6 +50,8 @@ type OpenAIChatCompletionRequest struct {
 	Messages      []map[string]interface{} `json:"messages"`
 	Stream        bool                     `json:"stream"`
 	StreamOptions map[string]interface{}   `json:"stream_options,omitempty"`
+	Temperature   *float64                 `json:"temperature,omitempty"`
+	Tools         []map[string]interface{} `json:"tools,omitempty"`
 }
 
 // OpenAIUsage represents the token usage information
@@ -90,8 +97,11 @@ func loadConfig() (*Config, error) {
 		return nil, fmt.Errorf("OPENAI_API_KEY is not set in the environment")
 	}
 
+	// ANTHROPIC_API_KEY is optional: only required when a request is routed
+	// to the Anthropic provider.
 	return &Config{
-		OpenAIAPIKey: apiKey,
+		OpenAIAPIKey:    apiKey,
+		AnthropicAPIKey: os.Getenv("ANTHROPIC_API_KEY"),
 	}, nil
 }
 
@@ -102,11 +112,54 @@ func main() {
 		log.Fatalf("Failed to load configuration: %v", err)
 	}
 
-	// Set up the HTTP server
-	http.HandleFunc(ENDPOINT, createChatCompletionsHandler(config))
+	// Load the optional multi-tenant configuration alongside .env
+	proxyConfig, err := loadProxyConfig(proxyConfigPath)
+	if err != nil {
+		log.Fatalf("Failed to load proxy configuration: %v", err)
+	}
+
+	limiter := NewRateLimiter()
+	cache := NewMemoryLRUCache(defaultCacheCapacity)
+
+	// Chain the built-in middlewares around the chat completions handler:
+	// logging wraps everything so it can time auth failures and rate-limit
+	// rejections too, auth resolves the tenant, and rate limiting needs
+	// that tenant to key its per-minute caps.
+	handler := chainMiddleware(
+		createChatCompletionsHandler(config, cache),
+		loggingMiddleware(),
+		authMiddleware(proxyConfig),
+		rateLimitMiddleware(limiter),
+	)
+
+	mux := http.NewServeMux()
+	mux.Handle(ENDPOINT, handler)
+	mux.HandleFunc("/healthz", healthzHandler)
+	mux.HandleFunc("/readyz", readyzHandler)
+
+	srv := &http.Server{Addr: ":" + PORT, Handler: mux}
+
+	go func() {
+		fmt.Printf("Starting server on port %s...\n", PORT)
+		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Fatalf("Server error: %v", err)
+		}
+	}()
+
+	// Block until we're asked to stop, then drain in-flight requests
+	// (including open streams) before the process exits.
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, os.Interrupt, syscall.SIGTERM)
+	<-sigCh
 
-	fmt.Printf("Starting server on port %s...\n", PORT)
-	log.Fatal(http.ListenAndServe(":"+PORT, nil))
+	fmt.Println("Shutting down, draining in-flight requests...")
+	atomic.StoreInt32(&ready, 0)
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeoutFromEnv())
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		log.Printf("Graceful shutdown did not complete cleanly: %v", err)
+	}
 }
 
 // extractText extracts text content from different provider response formats
@@ -153,8 +206,11 @@ type TimingInfo struct {
 	completionTime      time.Time
 }
 
-// createChatCompletionsHandler creates a handler for the chat completions endpoint
-func createChatCompletionsHandler(config *Config) http.HandlerFunc {
+// createChatCompletionsHandler creates a handler for the chat completions
+// endpoint. Responses are cached by tenant and request content (model,
+// messages, stream, temperature, tools) unless the client sends "Cache-Control: no-store",
+// so identical requests can be replayed without a round trip upstream.
+func createChatCompletionsHandler(config *Config, cache CacheBackend) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		// Initialize timing info
 		timing := TimingInfo{
@@ -167,21 +223,51 @@ func createChatCompletionsHandler(config *Config) http.HandlerFunc {
 			return
 		}
 
-		// Create a new request to OpenAI
+		// Resolve the tenant's upstream credentials, falling back to the
+		// process-wide defaults in single-tenant mode
+		tenant := tenantFromContext(r.Context())
+		effectiveConfig := tenant.effectiveConfig(config)
+
+		// Create a new request to the selected upstream provider
 		timing.requestPrepStart = time.Now()
-		forwardedReq, err := createOpenAIRequest(r, config)
+		forwardedReq, provider, chatReq, err := createOpenAIRequest(r, effectiveConfig)
 		if err != nil {
 			http.Error(w, fmt.Sprintf("Error creating request: %v", err), http.StatusInternalServerError)
 			return
 		}
 		timing.requestPrepEnd = time.Now()
 
-		// Send the request to OpenAI
+		accessLog := accessLogFromContext(r.Context())
+		accessLog.Model = chatReq.Model
+
+		// Serve from cache when possible, skipping the upstream round trip
+		// entirely.
+		cacheable := !wantsNoStore(r)
+		var cacheKey string
+		if cacheable {
+			cacheKey = requestCacheKey(tenant.Name, chatReq)
+			if cached, ok := cache.Get(cacheKey); ok && cached.IsStreaming == chatReq.Stream {
+				fmt.Printf("%s%sServing cached response%s\n", colorBold, colorMagenta, colorReset)
+				replayCachedResponse(w, cached)
+				if usage, found := cachedUsage(cached); found {
+					accessLog.PromptTokens = usage.PromptTokens
+					accessLog.CompletionTokens = usage.CompletionTokens
+					recordTokenUsage(r.Context(), usage.TotalTokens)
+				}
+				timing.completionTime = time.Now()
+				printTimingSummary(&timing, chatReq.Stream)
+				return
+			}
+		}
+
+		fmt.Printf("%s%sRouting to provider: %s%s\n", colorBold, colorMagenta, provider.Name(), colorReset)
+
+		// Send the request to the upstream provider
 		timing.providerCallStart = time.Now()
 		client := &http.Client{}
 		resp, err := client.Do(forwardedReq)
 		if err != nil {
-			http.Error(w, fmt.Sprintf("Error sending request to OpenAI: %v", err), http.StatusInternalServerError)
+			http.Error(w, fmt.Sprintf("Error sending request to provider: %v", err), http.StatusInternalServerError)
 			return
 		}
 		timing.providerResponseEnd = time.Now()
@@ -199,27 +285,67 @@ func createChatCompletionsHandler(config *Config) http.HandlerFunc {
 		w.WriteHeader(resp.StatusCode)
 
 		// Handle streaming response
-		if isStreamingRequest(r) {
-			handleStreamingResponse(w, resp.Body, &timing)
+		if chatReq.Stream {
+			// Only cache successful, fully-delivered streams: a transient
+			// upstream 4xx/5xx must never poison the cache, and a stream cut
+			// short by client disconnect must never be replayed as if it
+			// finished normally.
+			cacheableStream := cacheable && resp.StatusCode >= 200 && resp.StatusCode < 300
+			var recorder *cacheRecorder
+			if cacheableStream {
+				recorder = newCacheRecorder()
+			}
+			completed := handleStreamingResponse(r.Context(), w, resp.Body, &timing, provider, chatReq, recorder)
+			if recorder != nil && completed {
+				cache.Set(cacheKey, recorder.Result(), defaultCacheTTL)
+			}
 		} else {
 			// For non-streaming responses
 			body, err := io.ReadAll(resp.Body)
 			if err != nil {
-				http.Error(w, fmt.Sprintf("Error reading response from OpenAI: %v", err), http.StatusInternalServerError)
+				http.Error(w, fmt.Sprintf("Error reading response from provider: %v", err), http.StatusInternalServerError)
+				return
+			}
+
+			translatedBody, err := provider.TranslateResponseBody(body)
+			if err != nil {
+				http.Error(w, fmt.Sprintf("Error translating response from provider: %v", err), http.StatusInternalServerError)
 				return
 			}
 
+			// If the provider didn't report real usage, synthesize it so
+			// downstream billing/metering always sees consistent fields.
+			estimated := !hasRealUsage(translatedBody)
+			if estimated {
+				translatedBody, err = injectEstimatedUsage(translatedBody, chatReq)
+				if err != nil {
+					http.Error(w, fmt.Sprintf("Error estimating usage: %v", err), http.StatusInternalServerError)
+					return
+				}
+			}
+
 			// Log the response summary
 			fmt.Printf("%s%sResponse from provider:%s\n", colorBold, colorBlue, colorReset)
 
 			// Write response back to client
-			w.Write(body)
+			w.Write(translatedBody)
+
+			// Only cache successful responses: a transient upstream 4xx/5xx
+			// must never poison the cache for other callers of this key.
+			if cacheable && resp.StatusCode >= 200 && resp.StatusCode < 300 {
+				cache.Set(cacheKey, &CachedResponse{Body: translatedBody}, defaultCacheTTL)
+			}
 
 			// Record completion time
 			timing.completionTime = time.Now()
 
 			// Extract and log usage information if present
-			extractAndLogUsage(body)
+			extractAndLogUsage(translatedBody, estimated)
+			if usage, found := parseUsage(translatedBody); found {
+				accessLog.PromptTokens = usage.PromptTokens
+				accessLog.CompletionTokens = usage.CompletionTokens
+				recordTokenUsage(r.Context(), usage.TotalTokens)
+			}
 
 			// Print timing summary
 			printTimingSummary(&timing, false)
@@ -227,20 +353,35 @@ func createChatCompletionsHandler(config *Config) http.HandlerFunc {
 	}
 }
 
-// createOpenAIRequest creates a new request to be sent to OpenAI
-func createOpenAIRequest(r *http.Request, config *Config) (*http.Request, error) {
+// createOpenAIRequest creates a new request to be sent to the upstream
+// provider selected for this request (OpenAI, Anthropic, or OpenAI's
+// Responses API), translating the body into that provider's wire format. It
+// also returns the parsed chat completion request so callers can inspect
+// fields (model, messages, stream_options) without re-reading the body.
+func createOpenAIRequest(r *http.Request, config *Config) (*http.Request, Provider, OpenAIChatCompletionRequest, error) {
 	// Read the request body
 	body, err := io.ReadAll(r.Body)
 	if err != nil {
-		return nil, fmt.Errorf("error reading request body: %v", err)
+		return nil, nil, OpenAIChatCompletionRequest{}, fmt.Errorf("error reading request body: %v", err)
 	}
 	r.Body.Close()
 	r.Body = io.NopCloser(bytes.NewBuffer(body))
 
-	// Create a new request to OpenAI
-	req, err := http.NewRequest(r.Method, OPENAI_API_URL, bytes.NewBuffer(body))
+	var chatReq OpenAIChatCompletionRequest
+	if err := json.Unmarshal(body, &chatReq); err != nil {
+		return nil, nil, OpenAIChatCompletionRequest{}, fmt.Errorf("error parsing chat completion request: %v", err)
+	}
+
+	provider := selectProvider(r, chatReq.Model)
+	translatedBody, err := provider.TranslateRequestBody(body)
 	if err != nil {
-		return nil, fmt.Errorf("error creating request: %v", err)
+		return nil, nil, OpenAIChatCompletionRequest{}, fmt.Errorf("error translating request for provider %s: %v", provider.Name(), err)
+	}
+
+	// Create a new request to the selected provider
+	req, err := http.NewRequestWithContext(r.Context(), r.Method, provider.APIURL(), bytes.NewBuffer(translatedBody))
+	if err != nil {
+		return nil, nil, OpenAIChatCompletionRequest{}, fmt.Errorf("error creating request: %v", err)
 	}
 
 	// Copy headers from original request to new request, skipping "Posit" headers
@@ -252,107 +393,178 @@ func createOpenAIRequest(r *http.Request, config *Config) (*http.Request, error)
 		}
 	}
 
-	// Set content type and authorization headers
+	// Set content type and the auth header this provider expects. Providers
+	// that speak the OpenAI format forward the body (and any
+	// stream_options) unchanged, so stream_options.include_usage reaches
+	// OpenAI as-is.
 	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "Bearer "+config.OpenAIAPIKey)
+	provider.SetAuthHeader(req, provider.APIKey(config))
 
-	return req, nil
+	return req, provider, chatReq, nil
 }
 
-// isStreamingRequest checks if the request is a streaming request
-func isStreamingRequest(r *http.Request) bool {
-	body, err := io.ReadAll(r.Body)
-	if err != nil {
-		return false
-	}
-	defer r.Body.Close()
-	r.Body = io.NopCloser(bytes.NewBuffer(body))
-
-	var req OpenAIChatCompletionRequest
-	err = json.Unmarshal(body, &req)
-	if err != nil {
-		return false
-	}
-
-	return req.Stream
+// wantsUsage reports whether the client asked for usage to be included via
+// stream_options.include_usage.
+func wantsUsage(chatReq OpenAIChatCompletionRequest) bool {
+	include, ok := chatReq.StreamOptions["include_usage"].(bool)
+	return ok && include
 }
 
-// handleStreamingResponse handles the streaming response from LLM providers
-func handleStreamingResponse(w http.ResponseWriter, responseBody io.ReadCloser, timing *TimingInfo) {
+// handleStreamingResponse handles the streaming response from LLM providers,
+// translating each SSE chunk from the upstream provider's wire format into
+// OpenAI chat completion chunks before forwarding it to the client. If the
+// client asked for stream_options.include_usage and the provider never
+// reports real usage, a synthesized usage chunk is appended before [DONE].
+// When recorder is non-nil, every chunk sent to the client is also recorded
+// for cache replay. It returns whether the stream reached its natural end;
+// false means the client disconnected mid-stream, so callers must not treat
+// whatever was recorded as a complete, cacheable response.
+func handleStreamingResponse(ctx context.Context, w http.ResponseWriter, responseBody io.ReadCloser, timing *TimingInfo, provider Provider, chatReq OpenAIChatCompletionRequest, recorder *cacheRecorder) bool {
 	scanner := bufio.NewScanner(responseBody)
+	includeUsage := wantsUsage(chatReq)
+	translator := provider.NewStreamTranslator(includeUsage)
+	promptTokenEstimate := estimatePromptTokens(chatReq.Messages)
+	var completionText strings.Builder
 	var accumulatedText []string
-	var usageLine []byte // Store usage data for display at the end
+	var usageLine []byte // Store the usage chunk (real or synthesized), if any, for display at the end
 
 	// Flush header immediately
 	if flusher, ok := w.(http.Flusher); ok {
 		flusher.Flush()
 	}
 
-	for scanner.Scan() {
-		// Record time of first chunk if we haven't yet
-		if timing.firstChunkTime.IsZero() {
-			timing.firstChunkTime = time.Now()
-		}
+	// The writer goroutine decouples client writes from the scanner loop
+	// below, coalescing flushes instead of flushing once per chunk. Its
+	// synchronous pipe is the backpressure point: a slow client stalls the
+	// scanner rather than letting buffered chunks grow without limit.
+	sw := newStreamWriter(w, flushPolicyFromEnv())
+	defer sw.Close()
+
+	estimatedUsage := false
+	doneSent := false
+
+	// writeChunk sends one already-translated OpenAI chunk to the client,
+	// tracking usage and accumulated completion text as it goes.
+	writeChunk := func(chunk []byte) {
+		sw.Write(append(append([]byte("data: "), chunk...), '\n'))
+
+		prettyJSON := prettyPrintJSON(string(chunk))
+		fmt.Printf("%sdata: %s%s\n\n", colorCyan, prettyJSON, colorReset)
 
-		line := scanner.Bytes()
-		if len(line) == 0 {
-			continue
+		if hasRealUsage(chunk) {
+			usageLine = append([]byte(nil), chunk...)
 		}
 
-		// Process the line
+		if recorder != nil {
+			recorder.Add(chunk)
+		}
 
-		// Check if the line starts with "data: "
-		if bytes.HasPrefix(line, []byte("data: ")) {
-			dataStr := line[6:] // Remove 'data: ' prefix
+		if text := extractText(chunk); text != "" {
+			completionText.WriteString(text)
+			if ACCUMULATE_TEXT {
+				accumulatedText = append(accumulatedText, text)
+			}
+		}
+	}
 
-			// Try to parse the JSON
-			var data interface{}
-			if err := json.Unmarshal(dataStr, &data); err == nil && ACCUMULATE_TEXT {
-				// Extract text using provider-specific extraction
-				text := extractText(dataStr)
-				if text != "" {
-					accumulatedText = append(accumulatedText, text)
-				}
+	// sendDone appends a synthesized usage chunk (if the client asked for
+	// one and the provider never emitted real usage) and terminates the
+	// stream with "data: [DONE]".
+	sendDone := func() {
+		if includeUsage && !translator.UsageEmitted() {
+			usage := synthesizeUsage(promptTokenEstimate, estimateTokens(completionText.String()))
+			estimatedUsage = true
+			if chunk, err := json.Marshal(openAIUsageChunk(usage)); err == nil {
+				writeChunk(chunk)
 			}
 		}
+		sw.Write([]byte("data: [DONE]\n"))
+		doneSent = true
+	}
 
-		// Save the line if it contains usage information for later display
-		if bytes.HasPrefix(line, []byte("data: ")) && bytes.Contains(line, []byte("usage")) {
-			// Store the usage data line for later processing
-			usageLine = make([]byte, len(line))
-			copy(usageLine, line)
+	// Read upstream on a goroutine borrowed from the shared, bounded
+	// upstreamReadPool (rather than spawning one per request) so the loop
+	// below can select on ctx.Done() instead of blocking inside
+	// scanner.Scan(). Once the client disconnects and ctx is canceled,
+	// this goroutine stops forwarding lines and exits as soon as its next
+	// Scan() call returns (the upstream request was created with that
+	// same context, so the read unblocks almost immediately).
+	lines := make(chan []byte)
+	upstreamReadPool.Submit(func() {
+		defer close(lines)
+		for scanner.Scan() {
+			line := append([]byte(nil), scanner.Bytes()...)
+			select {
+			case lines <- line:
+			case <-ctx.Done():
+				return
+			}
 		}
+	})
+
+	disconnected := false
+scanning:
+	for {
+		select {
+		case line, ok := <-lines:
+			if !ok {
+				break scanning
+			}
 
-		// Write the line to the response with colorized output for debugging
-		if bytes.HasPrefix(line, []byte("data: ")) {
-			// Send the original data line to the client
-			w.Write(line)
-			w.Write([]byte("\n"))
+			// Record time of first chunk if we haven't yet
+			if timing.firstChunkTime.IsZero() {
+				timing.firstChunkTime = time.Now()
+			}
 
-			// Print colorized debug info to the console with pretty formatting
-			dataStr := line[6:] // Remove 'data: ' prefix
+			if len(line) == 0 {
+				continue
+			}
 
-			// Pretty print the JSON while maintaining field order
-			prettyJSON := prettyPrintJSON(string(dataStr))
-			fmt.Printf("%sdata: %s%s\n\n", colorCyan, prettyJSON, colorReset)
-		} else {
-			// For non-data lines (like empty lines that are part of SSE protocol)
-			w.Write(line)
-			w.Write([]byte("\n"))
-		}
+			// Translate the line into OpenAI chunk(s) and write each to the
+			// response, with colorized output for debugging
+			if bytes.HasPrefix(line, []byte("data: ")) {
+				dataStr := line[6:] // Remove 'data: ' prefix
 
-		// Flush to send the chunk immediately for responsive streaming
-		if flusher, ok := w.(http.Flusher); ok {
-			flusher.Flush()
+				if string(dataStr) == "[DONE]" {
+					sendDone()
+				} else {
+					for _, translated := range translator.Translate(dataStr) {
+						writeChunk(translated)
+					}
+				}
+			} else {
+				// For non-data lines (like empty lines that are part of SSE protocol)
+				sw.Write(append(append([]byte(nil), line...), '\n'))
+			}
+		case <-ctx.Done():
+			fmt.Printf("%s%sClient disconnected, aborting upstream stream%s\n", colorBold, colorRed, colorReset)
+			disconnected = true
+			break scanning
 		}
 	}
 
+	// Providers whose stream doesn't end with a literal "[DONE]" (Anthropic,
+	// the Responses API) rely on us to send one once their connection
+	// closes. Skip it if the client is already gone.
+	if !doneSent && !disconnected && !provider.NativeDoneMarker() {
+		sendDone()
+	}
+
 	// Record completion time
 	timing.completionTime = time.Now()
 
 	// Extract and log usage information if available
 	if len(usageLine) > 0 {
-		extractAndLogUsage(usageLine)
+		extractAndLogUsage(usageLine, estimatedUsage)
+		if usage, found := parseUsage(usageLine); found {
+			accessLog := accessLogFromContext(ctx)
+			accessLog.PromptTokens = usage.PromptTokens
+			accessLog.CompletionTokens = usage.CompletionTokens
+			if !timing.firstChunkTime.IsZero() {
+				accessLog.FirstChunkLatencyMs = timing.firstChunkTime.Sub(timing.requestStart).Milliseconds()
+			}
+			recordTokenUsage(ctx, usage.TotalTokens)
+		}
 	}
 
 	// Print timing summary
@@ -366,6 +578,8 @@ func handleStreamingResponse(w http.ResponseWriter, responseBody io.ReadCloser,
 		fmt.Println(strings.Join(accumulatedText, ""))
 		fmt.Printf("%s===========================================================%s\n", colorYellow, colorReset)
 	}
+
+	return !disconnected
 }
 
 // printTimingSummary prints a detailed summary of all timing metrics
@@ -487,62 +701,22 @@ func prettyPrintJSON(jsonStr string) string {
 	return prettyStr.String()
 }
 
-// extractAndLogUsage extracts and logs the token usage information
-func extractAndLogUsage(data []byte) {
-	// Check if we need to extract from "data:" prefix
-	var jsonData []byte
-	if bytes.HasPrefix(data, []byte("data: ")) {
-		jsonData = data[6:] // Remove 'data: ' prefix
-	} else {
-		jsonData = data
-	}
-
-	// Try to parse the JSON
-	var responseObj map[string]interface{}
-	if err := json.Unmarshal(jsonData, &responseObj); err != nil {
-		fmt.Printf("%sFailed to parse usage info: %v%s\n", colorRed, err, colorReset)
+// extractAndLogUsage extracts and logs the token usage information. The
+// estimated flag reports whether the usage came from the provider itself
+// or was synthesized locally because the provider didn't report any.
+func extractAndLogUsage(data []byte, estimated bool) {
+	usage, found := parseUsage(data)
+	if !found {
 		return
 	}
 
-	// Variables to store usage information
-	var promptTokens, completionTokens, totalTokens int
-	var foundUsage bool
-
-	// Check if the response has usage information directly
-	if usage, ok := responseObj["usage"].(map[string]interface{}); ok {
-		// Handle OpenAI format
-		if pt, ok := usage["prompt_tokens"].(float64); ok {
-			promptTokens = int(pt)
-			completionTokens = int(usage["completion_tokens"].(float64))
-			totalTokens = int(usage["total_tokens"].(float64))
-			foundUsage = true
-		}
-	}
-
-	// If not found in standard format, try alternative formats
-	if !foundUsage {
-		// For OpenAI streaming responses, usage might be in a different format
-		if choices, ok := responseObj["choices"].([]interface{}); ok && len(choices) > 0 {
-			choice := choices[0].(map[string]interface{})
-			if finish_reason, ok := choice["finish_reason"].(string); ok && finish_reason != "" {
-				if usage, ok := responseObj["usage"].(map[string]interface{}); ok {
-					if pt, ok := usage["prompt_tokens"].(float64); ok {
-						promptTokens = int(pt)
-						completionTokens = int(usage["completion_tokens"].(float64))
-						totalTokens = int(usage["total_tokens"].(float64))
-						foundUsage = true
-					}
-				}
-			}
-		}
-	}
-
-	// Print usage summary if found
-	if foundUsage {
-		fmt.Printf("\n%s%s============= TOKEN USAGE SUMMARY ==============%s\n", colorBold, colorGreen, colorReset)
-		fmt.Printf("%s• Input tokens:  %d%s\n", colorGreen, promptTokens, colorReset)
-		fmt.Printf("%s• Output tokens: %d%s\n", colorGreen, completionTokens, colorReset)
-		fmt.Printf("%s• Total tokens:  %d%s\n", colorGreen, totalTokens, colorReset)
-		fmt.Printf("%s%s=================================================%s\n", colorBold, colorGreen, colorReset)
+	source := "reported by provider"
+	if estimated {
+		source = "estimated locally"
 	}
+	fmt.Printf("\n%s%s============= TOKEN USAGE SUMMARY (%s) ==============%s\n", colorBold, colorGreen, source, colorReset)
+	fmt.Printf("%s• Input tokens:  %d%s\n", colorGreen, usage.PromptTokens, colorReset)
+	fmt.Printf("%s• Output tokens: %d%s\n", colorGreen, usage.CompletionTokens, colorReset)
+	fmt.Printf("%s• Total tokens:  %d%s\n", colorGreen, usage.TotalTokens, colorReset)
+	fmt.Printf("%s%s=================================================%s\n", colorBold, colorGreen, colorReset)
 }
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Middleware wraps an http.Handler with additional behavior, chained in
+// main so auth, rate limiting and access logging compose around the chat
+// completions handler.
+type Middleware func(http.Handler) http.Handler
+
+// chainMiddleware applies mws to h in order, so the first middleware in the
+// list is the outermost one a request passes through.
+func chainMiddleware(h http.Handler, mws ...Middleware) http.Handler {
+	for i := len(mws) - 1; i >= 0; i-- {
+		h = mws[i](h)
+	}
+	return h
+}
+
+// peekChatRequest parses the request body as an OpenAIChatCompletionRequest
+// without consuming it, so middleware can inspect fields like Model before
+// the real handler reads the body again.
+func peekChatRequest(r *http.Request) (OpenAIChatCompletionRequest, error) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return OpenAIChatCompletionRequest{}, err
+	}
+	r.Body.Close()
+	r.Body = io.NopCloser(bytes.NewBuffer(body))
+
+	var chatReq OpenAIChatCompletionRequest
+	if err := json.Unmarshal(body, &chatReq); err != nil {
+		return OpenAIChatCompletionRequest{}, err
+	}
+	return chatReq, nil
+}
+
+// authMiddleware maps an inbound "Authorization: Bearer <key>" to a tenant
+// configured in config.yaml, storing it on the request context. When no
+// tenants are configured it's a no-op, preserving single-tenant behavior.
+// It also fills in the access log entry's Tenant field, since loggingMiddleware
+// wraps this one and resolves the tenant before it runs.
+func authMiddleware(pc *ProxyConfig) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if len(pc.Tenants) == 0 {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			apiKey := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+			tenant, ok := pc.tenantByAPIKey(apiKey)
+			if !ok {
+				http.Error(w, "Invalid API key", http.StatusUnauthorized)
+				return
+			}
+
+			accessLogFromContext(r.Context()).Tenant = tenant.Name
+			next.ServeHTTP(w, r.WithContext(withTenant(r.Context(), tenant)))
+		})
+	}
+}
+
+type rateLimitRecorderKey struct{}
+
+// recordTokenUsage reports n tokens against the rate limit window that
+// authorized this request, if rate limiting is enabled.
+func recordTokenUsage(ctx context.Context, n int) {
+	if record, ok := ctx.Value(rateLimitRecorderKey{}).(func(int)); ok {
+		record(n)
+	}
+}
+
+// rateLimitMiddleware enforces per-minute request and token caps keyed by
+// tenant and model, using the tenant's configured TenantLimits.
+func rateLimitMiddleware(limiter *RateLimiter) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			tenant := tenantFromContext(r.Context())
+
+			chatReq, err := peekChatRequest(r)
+			if err != nil {
+				http.Error(w, fmt.Sprintf("Error parsing request: %v", err), http.StatusBadRequest)
+				return
+			}
+
+			key := tenant.Name + ":" + chatReq.Model
+			if !limiter.Allow(key, tenant.Limits) {
+				http.Error(w, "Rate limit exceeded", http.StatusTooManyRequests)
+				return
+			}
+
+			record := func(n int) { limiter.RecordTokens(key, n) }
+			ctx := context.WithValue(r.Context(), rateLimitRecorderKey{}, record)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// AccessLogEntry is one structured JSON access log line per request.
+type AccessLogEntry struct {
+	Tenant              string `json:"tenant"`
+	Model               string `json:"model"`
+	StatusCode          int    `json:"status_code"`
+	PromptTokens        int    `json:"prompt_tokens"`
+	CompletionTokens    int    `json:"completion_tokens"`
+	FirstChunkLatencyMs int64  `json:"first_chunk_latency_ms,omitempty"`
+	TotalLatencyMs      int64  `json:"total_latency_ms"`
+}
+
+type accessLogContextKey struct{}
+
+// accessLogFromContext returns the AccessLogEntry the handler should fill in
+// as it learns the model, token usage and timing for this request.
+func accessLogFromContext(ctx context.Context) *AccessLogEntry {
+	if entry, ok := ctx.Value(accessLogContextKey{}).(*AccessLogEntry); ok {
+		return entry
+	}
+	return &AccessLogEntry{}
+}
+
+// statusRecorder wraps an http.ResponseWriter to capture the status code
+// written, since http.ResponseWriter doesn't expose it after the fact.
+type statusRecorder struct {
+	http.ResponseWriter
+	statusCode int
+}
+
+func (sr *statusRecorder) WriteHeader(code int) {
+	sr.statusCode = code
+	sr.ResponseWriter.WriteHeader(code)
+}
+
+// loggingMiddleware records one structured JSON access log line per request,
+// populated as later middleware and the handler learn the tenant, model,
+// token usage and timing via accessLogFromContext. It wraps authMiddleware,
+// so the tenant isn't known yet when entry is created here; authMiddleware
+// fills in entry.Tenant once it resolves the API key, and entry stays
+// "default" for single-tenant setups where auth is a no-op.
+func loggingMiddleware() Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			entry := &AccessLogEntry{Tenant: tenantFromContext(r.Context()).Name}
+			ctx := context.WithValue(r.Context(), accessLogContextKey{}, entry)
+
+			sr := &statusRecorder{ResponseWriter: w, statusCode: http.StatusOK}
+			start := time.Now()
+
+			next.ServeHTTP(sr, r.WithContext(ctx))
+
+			entry.StatusCode = sr.statusCode
+			entry.TotalLatencyMs = time.Since(start).Milliseconds()
+
+			if line, err := json.Marshal(entry); err == nil {
+				fmt.Printf("%s%saccess: %s%s\n", colorMagenta, colorBold, line, colorReset)
+			}
+		})
+	}
+}
@@ -0,0 +1,485 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+const (
+	ANTHROPIC_API_URL         = "https://api.anthropic.com/v1/messages"
+	ANTHROPIC_VERSION         = "2023-06-01"
+	OPENAI_RESPONSES_API_URL  = "https://api.openai.com/v1/responses"
+	PROVIDER_HEADER           = "X-LLM-Provider"
+	anthropicDefaultMaxTokens = 4096
+)
+
+// Provider abstracts over an upstream LLM backend: where to send the request,
+// how to authenticate, and how to translate between the OpenAI chat
+// completions wire format (what clients of this proxy speak) and whatever
+// format the upstream actually expects.
+type Provider interface {
+	// Name identifies the provider for logging and the X-LLM-Provider header.
+	Name() string
+
+	// APIURL returns the upstream endpoint to forward the request to.
+	APIURL() string
+
+	// APIKey returns the API key configured for this provider.
+	APIKey(config *Config) string
+
+	// SetAuthHeader applies whatever auth scheme this provider expects.
+	SetAuthHeader(req *http.Request, apiKey string)
+
+	// TranslateRequestBody rewrites an OpenAI-style chat completion request
+	// body into the shape this provider expects. Providers that already speak
+	// the OpenAI format return the body unchanged.
+	TranslateRequestBody(body []byte) ([]byte, error)
+
+	// TranslateResponseBody rewrites a non-streaming upstream response body
+	// back into OpenAI chat completion format.
+	TranslateResponseBody(body []byte) ([]byte, error)
+
+	// NativeDoneMarker reports whether this provider's own stream ends with a
+	// literal "data: [DONE]" line, the way OpenAI's does. Providers that
+	// don't (Anthropic, the Responses API) rely on handleStreamingResponse to
+	// synthesize one once their stream closes.
+	NativeDoneMarker() bool
+
+	// NewStreamTranslator returns a fresh StreamTranslator for a single
+	// streaming request. Translators may carry state across calls (e.g. to
+	// remember prompt tokens seen in an earlier event), so a new one must be
+	// created per request. includeUsage reports whether the client asked
+	// for stream_options.include_usage, so translators whose upstream
+	// reports usage unconditionally (unlike OpenAI, which only reports it
+	// when asked) know whether to forward it to the client.
+	NewStreamTranslator(includeUsage bool) StreamTranslator
+}
+
+// StreamTranslator converts one upstream provider's SSE event stream into
+// OpenAI-style "data: " chunks, for the lifetime of a single request.
+type StreamTranslator interface {
+	// Translate converts one upstream SSE "data: " payload (without the
+	// prefix) into zero or more OpenAI-style "data: " payloads to forward to
+	// the client.
+	Translate(data []byte) [][]byte
+
+	// UsageEmitted reports whether a chunk carrying real token usage has
+	// already been produced, so callers know whether they still need to
+	// synthesize one.
+	UsageEmitted() bool
+}
+
+// selectProvider picks the upstream Provider for a request, preferring an
+// explicit X-LLM-Provider header and falling back to inferring from the
+// model name.
+func selectProvider(r *http.Request, model string) Provider {
+	switch strings.ToLower(r.Header.Get(PROVIDER_HEADER)) {
+	case "anthropic":
+		return anthropicProvider{}
+	case "openai-responses":
+		return openAIResponsesProvider{}
+	case "openai":
+		return openAIProvider{}
+	}
+
+	if strings.HasPrefix(model, "claude") {
+		return anthropicProvider{}
+	}
+	if strings.HasPrefix(model, "responses:") {
+		return openAIResponsesProvider{}
+	}
+	return openAIProvider{}
+}
+
+// openAIProvider talks to the OpenAI chat completions API, which is the
+// proxy's own native wire format, so no translation is needed.
+type openAIProvider struct{}
+
+func (openAIProvider) Name() string            { return "openai" }
+func (openAIProvider) APIURL() string          { return OPENAI_API_URL }
+func (openAIProvider) APIKey(c *Config) string { return c.OpenAIAPIKey }
+
+func (openAIProvider) SetAuthHeader(req *http.Request, apiKey string) {
+	req.Header.Set("Authorization", "Bearer "+apiKey)
+}
+
+func (openAIProvider) TranslateRequestBody(body []byte) ([]byte, error) {
+	return body, nil
+}
+
+func (openAIProvider) TranslateResponseBody(body []byte) ([]byte, error) {
+	return body, nil
+}
+
+func (openAIProvider) NativeDoneMarker() bool { return true }
+
+func (openAIProvider) NewStreamTranslator(includeUsage bool) StreamTranslator {
+	return &passthroughStreamTranslator{}
+}
+
+// passthroughStreamTranslator forwards upstream SSE payloads unchanged,
+// since OpenAI's chat completions stream is already the proxy's own wire
+// format. It still watches for a real "usage" object so callers know
+// whether they need to synthesize one.
+type passthroughStreamTranslator struct {
+	usageEmitted bool
+}
+
+func (t *passthroughStreamTranslator) Translate(data []byte) [][]byte {
+	if bytes.Contains(data, []byte(`"usage"`)) && !bytes.Contains(data, []byte(`"usage":null`)) {
+		t.usageEmitted = true
+	}
+	return [][]byte{data}
+}
+
+func (t *passthroughStreamTranslator) UsageEmitted() bool { return t.usageEmitted }
+
+// openAIResponsesProvider talks to OpenAI's /v1/responses API, translating
+// the chat-completions-shaped request/response into the Responses shape.
+type openAIResponsesProvider struct{}
+
+func (openAIResponsesProvider) Name() string            { return "openai-responses" }
+func (openAIResponsesProvider) APIURL() string          { return OPENAI_RESPONSES_API_URL }
+func (openAIResponsesProvider) APIKey(c *Config) string { return c.OpenAIAPIKey }
+
+func (openAIResponsesProvider) SetAuthHeader(req *http.Request, apiKey string) {
+	req.Header.Set("Authorization", "Bearer "+apiKey)
+}
+
+func (openAIResponsesProvider) TranslateRequestBody(body []byte) ([]byte, error) {
+	var chatReq OpenAIChatCompletionRequest
+	if err := json.Unmarshal(body, &chatReq); err != nil {
+		return nil, fmt.Errorf("error parsing chat completion request: %v", err)
+	}
+
+	input := make([]map[string]interface{}, 0, len(chatReq.Messages))
+	for _, msg := range chatReq.Messages {
+		input = append(input, map[string]interface{}{
+			"role":    msg["role"],
+			"content": msg["content"],
+		})
+	}
+
+	responsesReq := map[string]interface{}{
+		"model":  chatReq.Model,
+		"input":  input,
+		"stream": chatReq.Stream,
+	}
+	return json.Marshal(responsesReq)
+}
+
+func (openAIResponsesProvider) TranslateResponseBody(body []byte) ([]byte, error) {
+	var resp struct {
+		ID     string `json:"id"`
+		Model  string `json:"model"`
+		Output []struct {
+			Content []struct {
+				Text string `json:"text"`
+			} `json:"content"`
+		} `json:"output"`
+		Usage map[string]interface{} `json:"usage"`
+	}
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return nil, fmt.Errorf("error parsing responses API response: %v", err)
+	}
+
+	var text string
+	if len(resp.Output) > 0 && len(resp.Output[0].Content) > 0 {
+		text = resp.Output[0].Content[0].Text
+	}
+
+	chatResp := map[string]interface{}{
+		"id":     resp.ID,
+		"object": "chat.completion",
+		"model":  resp.Model,
+		"choices": []map[string]interface{}{
+			{
+				"index": 0,
+				"message": map[string]interface{}{
+					"role":    "assistant",
+					"content": text,
+				},
+				"finish_reason": "stop",
+			},
+		},
+		"usage": resp.Usage,
+	}
+	return json.Marshal(chatResp)
+}
+
+func (openAIResponsesProvider) NativeDoneMarker() bool { return false }
+
+func (openAIResponsesProvider) NewStreamTranslator(includeUsage bool) StreamTranslator {
+	return &responsesStreamTranslator{includeUsage: includeUsage}
+}
+
+// responsesStreamTranslator turns Responses API text-delta events into
+// OpenAI chat completion chunks. The Responses API reports usage on its
+// terminal "response.completed" event rather than per delta, and does so
+// regardless of what the client asked for, so the translator gates the
+// usage chunk on includeUsage itself rather than forwarding it
+// unconditionally (mirroring anthropicStreamTranslator).
+type responsesStreamTranslator struct {
+	includeUsage bool
+	usageEmitted bool
+}
+
+func (t *responsesStreamTranslator) Translate(data []byte) [][]byte {
+	var event struct {
+		Type     string `json:"type"`
+		Delta    string `json:"delta"`
+		Response struct {
+			Usage map[string]interface{} `json:"usage"`
+		} `json:"response"`
+	}
+	if err := json.Unmarshal(data, &event); err != nil {
+		return nil
+	}
+
+	switch event.Type {
+	case "response.output_text.delta":
+		if event.Delta == "" {
+			return nil
+		}
+		out, err := json.Marshal(openAIStreamChunk(event.Delta, ""))
+		if err != nil {
+			return nil
+		}
+		return [][]byte{out}
+	case "response.completed":
+		if !t.includeUsage || len(event.Response.Usage) == 0 {
+			return nil
+		}
+		t.usageEmitted = true
+		out, err := json.Marshal(openAIUsageChunk(event.Response.Usage))
+		if err != nil {
+			return nil
+		}
+		return [][]byte{out}
+	default:
+		return nil
+	}
+}
+
+func (t *responsesStreamTranslator) UsageEmitted() bool { return t.usageEmitted }
+
+// anthropicProvider talks to Anthropic's /v1/messages API, translating
+// between the OpenAI chat completions shape and Anthropic's messages shape.
+type anthropicProvider struct{}
+
+func (anthropicProvider) Name() string            { return "anthropic" }
+func (anthropicProvider) APIURL() string          { return ANTHROPIC_API_URL }
+func (anthropicProvider) APIKey(c *Config) string { return c.AnthropicAPIKey }
+
+func (anthropicProvider) SetAuthHeader(req *http.Request, apiKey string) {
+	req.Header.Set("x-api-key", apiKey)
+	req.Header.Set("anthropic-version", ANTHROPIC_VERSION)
+}
+
+func (anthropicProvider) TranslateRequestBody(body []byte) ([]byte, error) {
+	var chatReq OpenAIChatCompletionRequest
+	if err := json.Unmarshal(body, &chatReq); err != nil {
+		return nil, fmt.Errorf("error parsing chat completion request: %v", err)
+	}
+
+	var system string
+	messages := make([]map[string]interface{}, 0, len(chatReq.Messages))
+	for _, msg := range chatReq.Messages {
+		role, _ := msg["role"].(string)
+		if role == "system" {
+			if content, ok := msg["content"].(string); ok {
+				if system != "" {
+					system += "\n"
+				}
+				system += content
+			}
+			continue
+		}
+		messages = append(messages, map[string]interface{}{
+			"role":    role,
+			"content": msg["content"],
+		})
+	}
+
+	anthropicReq := map[string]interface{}{
+		"model":      chatReq.Model,
+		"messages":   messages,
+		"stream":     chatReq.Stream,
+		"max_tokens": anthropicDefaultMaxTokens,
+	}
+	if system != "" {
+		anthropicReq["system"] = system
+	}
+	return json.Marshal(anthropicReq)
+}
+
+func (anthropicProvider) TranslateResponseBody(body []byte) ([]byte, error) {
+	var resp struct {
+		ID      string `json:"id"`
+		Model   string `json:"model"`
+		Content []struct {
+			Type string `json:"type"`
+			Text string `json:"text"`
+		} `json:"content"`
+		StopReason string `json:"stop_reason"`
+		Usage      *struct {
+			InputTokens  int `json:"input_tokens"`
+			OutputTokens int `json:"output_tokens"`
+		} `json:"usage"`
+	}
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return nil, fmt.Errorf("error parsing Anthropic response: %v", err)
+	}
+
+	var text string
+	for _, block := range resp.Content {
+		if block.Type == "text" {
+			text += block.Text
+		}
+	}
+
+	var usage interface{}
+	if resp.Usage != nil {
+		usage = OpenAIUsage{
+			PromptTokens:     resp.Usage.InputTokens,
+			CompletionTokens: resp.Usage.OutputTokens,
+			TotalTokens:      resp.Usage.InputTokens + resp.Usage.OutputTokens,
+		}
+	}
+
+	chatResp := map[string]interface{}{
+		"id":     resp.ID,
+		"object": "chat.completion",
+		"model":  resp.Model,
+		"choices": []map[string]interface{}{
+			{
+				"index": 0,
+				"message": map[string]interface{}{
+					"role":    "assistant",
+					"content": text,
+				},
+				"finish_reason": resp.StopReason,
+			},
+		},
+		"usage": usage,
+	}
+	return json.Marshal(chatResp)
+}
+
+func (anthropicProvider) NativeDoneMarker() bool { return false }
+
+func (anthropicProvider) NewStreamTranslator(includeUsage bool) StreamTranslator {
+	return &anthropicStreamTranslator{includeUsage: includeUsage}
+}
+
+// anthropicStreamTranslator turns an Anthropic messages API event stream
+// into OpenAI chat completion chunks. Anthropic reports input tokens on
+// "message_start" and output tokens on "message_delta", so the translator
+// remembers the prompt token count to combine them into one usage chunk.
+// Unlike OpenAI, Anthropic reports usage on every message_delta regardless
+// of what the client asked for, so the translator gates the usage chunk on
+// includeUsage itself rather than forwarding it unconditionally.
+type anthropicStreamTranslator struct {
+	promptTokens int
+	includeUsage bool
+	usageEmitted bool
+}
+
+func (t *anthropicStreamTranslator) Translate(data []byte) [][]byte {
+	var event struct {
+		Type    string `json:"type"`
+		Message struct {
+			Usage struct {
+				InputTokens int `json:"input_tokens"`
+			} `json:"usage"`
+		} `json:"message"`
+		Delta struct {
+			Type string `json:"type"`
+			Text string `json:"text"`
+		} `json:"delta"`
+		Usage struct {
+			OutputTokens int `json:"output_tokens"`
+		} `json:"usage"`
+	}
+	if err := json.Unmarshal(data, &event); err != nil {
+		return nil
+	}
+
+	switch event.Type {
+	case "message_start":
+		t.promptTokens = event.Message.Usage.InputTokens
+		return nil
+	case "content_block_delta":
+		if event.Delta.Type != "text_delta" || event.Delta.Text == "" {
+			return nil
+		}
+		out, err := json.Marshal(openAIStreamChunk(event.Delta.Text, ""))
+		if err != nil {
+			return nil
+		}
+		return [][]byte{out}
+	case "message_delta":
+		finishOut, err := json.Marshal(openAIStreamChunk("", "stop"))
+		if err != nil {
+			return nil
+		}
+		if !t.includeUsage {
+			return [][]byte{finishOut}
+		}
+
+		completionTokens := event.Usage.OutputTokens
+		t.usageEmitted = true
+		usageOut, err := json.Marshal(openAIUsageChunk(OpenAIUsage{
+			PromptTokens:     t.promptTokens,
+			CompletionTokens: completionTokens,
+			TotalTokens:      t.promptTokens + completionTokens,
+		}))
+		if err != nil {
+			return [][]byte{finishOut}
+		}
+		return [][]byte{finishOut, usageOut}
+	default:
+		return nil
+	}
+}
+
+func (t *anthropicStreamTranslator) UsageEmitted() bool { return t.usageEmitted }
+
+// openAIStreamChunk builds a minimal OpenAI-style streaming chunk carrying
+// either a content delta or a finish reason, used by providers that need to
+// synthesize the OpenAI wire format from their own event stream.
+func openAIStreamChunk(content string, finishReason string) map[string]interface{} {
+	delta := map[string]interface{}{}
+	if content != "" {
+		delta["content"] = content
+	}
+
+	choice := map[string]interface{}{
+		"index": 0,
+		"delta": delta,
+	}
+	if finishReason != "" {
+		choice["finish_reason"] = finishReason
+	} else {
+		choice["finish_reason"] = nil
+	}
+
+	return map[string]interface{}{
+		"object":  "chat.completion.chunk",
+		"choices": []map[string]interface{}{choice},
+	}
+}
+
+// openAIUsageChunk builds the final OpenAI-style streaming chunk sent when
+// stream_options.include_usage is set: an empty choices list carrying the
+// usage object, per OpenAI's own convention for the trailing usage chunk.
+func openAIUsageChunk(usage interface{}) map[string]interface{} {
+	return map[string]interface{}{
+		"object":  "chat.completion.chunk",
+		"choices": []map[string]interface{}{},
+		"usage":   usage,
+	}
+}
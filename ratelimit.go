@@ -0,0 +1,64 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// RateLimiter enforces per-minute request and token caps per tenant+model.
+// Each key gets its own fixed window that resets once a minute has
+// elapsed since it was first touched.
+type RateLimiter struct {
+	mu      sync.Mutex
+	windows map[string]*rateWindow
+}
+
+type rateWindow struct {
+	start        time.Time
+	requestCount int
+	tokenCount   int
+}
+
+// NewRateLimiter creates an empty RateLimiter.
+func NewRateLimiter() *RateLimiter {
+	return &RateLimiter{windows: make(map[string]*rateWindow)}
+}
+
+// Allow reports whether another request under key may proceed given the
+// configured limits, and reserves one request against the cap if so. A
+// limit of 0 means "no cap" for that dimension.
+func (rl *RateLimiter) Allow(key string, limits TenantLimits) bool {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	w := rl.window(key)
+	if limits.RequestsPerMinute > 0 && w.requestCount >= limits.RequestsPerMinute {
+		return false
+	}
+	if limits.TokensPerMinute > 0 && w.tokenCount >= limits.TokensPerMinute {
+		return false
+	}
+
+	w.requestCount++
+	return true
+}
+
+// RecordTokens adds n tokens to key's current window, once a request's real
+// or estimated usage is known.
+func (rl *RateLimiter) RecordTokens(key string, n int) {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	rl.window(key).tokenCount += n
+}
+
+// window returns key's current window, resetting it if a minute has
+// elapsed. Callers must hold rl.mu.
+func (rl *RateLimiter) window(key string) *rateWindow {
+	w, ok := rl.windows[key]
+	if !ok || time.Since(w.start) >= time.Minute {
+		w = &rateWindow{start: time.Now()}
+		rl.windows[key] = w
+	}
+	return w
+}
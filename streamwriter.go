@@ -0,0 +1,177 @@
+package main
+
+import (
+	"bufio"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+)
+
+// Default coalesced-flush tuning for streaming responses, overridable via
+// env so deployments can trade latency for fewer flush syscalls under load.
+const (
+	defaultFlushInterval = 20 * time.Millisecond
+	defaultFlushEveryN   = 8
+	defaultReadPoolSize  = 512
+	defaultWritePoolSize = 512
+)
+
+// flushPolicy decides how often buffered chunks are flushed to the client:
+// whichever comes first, a chunk count or a time interval.
+type flushPolicy struct {
+	interval time.Duration
+	everyN   int
+}
+
+// flushPolicyFromEnv reads STREAM_FLUSH_INTERVAL_MS and STREAM_FLUSH_EVERY_N,
+// falling back to the package defaults for unset or invalid values.
+func flushPolicyFromEnv() flushPolicy {
+	return flushPolicy{
+		interval: envDurationMs("STREAM_FLUSH_INTERVAL_MS", defaultFlushInterval),
+		everyN:   envInt("STREAM_FLUSH_EVERY_N", defaultFlushEveryN),
+	}
+}
+
+func envDurationMs(key string, fallback time.Duration) time.Duration {
+	ms, err := strconv.Atoi(os.Getenv(key))
+	if err != nil || ms <= 0 {
+		return fallback
+	}
+	return time.Duration(ms) * time.Millisecond
+}
+
+func envInt(key string, fallback int) int {
+	n, err := strconv.Atoi(os.Getenv(key))
+	if err != nil || n <= 0 {
+		return fallback
+	}
+	return n
+}
+
+// workerPool bounds concurrent execution of one class of blocking work
+// (upstream reads or client writes) to a fixed number of goroutines, shared
+// across every in-flight request - the way bytedance/gopkg's gopool reuses
+// a bounded worker set instead of a caller spawning a fresh goroutine per
+// task. Submit blocks until a slot is free, so a burst of concurrent
+// streams queues for a worker rather than growing the goroutine count
+// without bound.
+type workerPool struct {
+	sem chan struct{}
+}
+
+// newWorkerPool creates a workerPool that runs at most size jobs at once.
+func newWorkerPool(size int) *workerPool {
+	return &workerPool{sem: make(chan struct{}, size)}
+}
+
+// Submit acquires a pool slot and runs fn on a pool goroutine, releasing the
+// slot once fn returns. It returns as soon as the goroutine running fn has
+// been launched, without waiting for fn to start or finish.
+func (p *workerPool) Submit(fn func()) {
+	p.sem <- struct{}{}
+	go func() {
+		defer func() { <-p.sem }()
+		fn()
+	}()
+}
+
+// upstreamReadPool and clientWritePool are the shared, bounded pools behind
+// every streaming request: one for the goroutines scanning upstream SSE
+// lines, one for the goroutines flushing rendered chunks to
+// clients. Sizes are read once at startup via env so a deployment can tune
+// them to its expected concurrent-stream count.
+var (
+	upstreamReadPool = newWorkerPool(envInt("STREAM_READ_POOL_SIZE", defaultReadPoolSize))
+	clientWritePool  = newWorkerPool(envInt("STREAM_WRITE_POOL_SIZE", defaultWritePoolSize))
+)
+
+// streamWriter decouples the upstream scanner loop from client writes via an
+// io.Pipe: Write feeds rendered SSE lines into the pipe from the caller's
+// goroutine (the one running handleStreamingResponse), while a dedicated goroutine
+// borrowed from clientWritePool reads them out the other end and flushes to
+// the client in coalesced batches instead of once per chunk. Because the
+// pipe is synchronous, a client that can't keep up blocks the writer side of
+// the pipe rather than letting buffered output grow without bound in
+// memory.
+type streamWriter struct {
+	w      http.ResponseWriter
+	pw     *io.PipeWriter
+	done   chan struct{}
+	policy flushPolicy
+}
+
+// newStreamWriter starts the writer goroutine on clientWritePool and returns
+// a streamWriter ready to accept lines. Callers must call Close once done
+// producing, to flush anything left buffered and wait for the writer
+// goroutine to exit.
+func newStreamWriter(w http.ResponseWriter, policy flushPolicy) *streamWriter {
+	pr, pw := io.Pipe()
+	sw := &streamWriter{
+		w:      w,
+		pw:     pw,
+		done:   make(chan struct{}),
+		policy: policy,
+	}
+	clientWritePool.Submit(func() { sw.run(pr) })
+	return sw
+}
+
+// Write queues one rendered line (including its trailing newline) to be
+// sent to the client. It blocks until the writer goroutine's next pipe read,
+// which is the intended backpressure: a slow client throttles how fast we
+// read from upstream rather than buffering unboundedly in memory.
+func (sw *streamWriter) Write(line []byte) {
+	sw.pw.Write(line)
+}
+
+// Close signals that no more lines will be queued, flushes anything left
+// buffered, and waits for the writer goroutine to exit.
+func (sw *streamWriter) Close() {
+	sw.pw.Close()
+	<-sw.done
+}
+
+// run reads rendered lines off the pipe and writes them to the client,
+// flushing whichever comes first: policy.everyN lines buffered, or
+// policy.interval elapsed since the last flush. Reads block on the pipe, so
+// the interval is checked opportunistically whenever a line arrives rather
+// than on a true ticker; in steady state, upstream SSE events arrive often
+// enough for this to behave the same, and Close always flushes whatever is
+// left pending.
+func (sw *streamWriter) run(pr *io.PipeReader) {
+	defer close(sw.done)
+
+	flusher, _ := sw.w.(http.Flusher)
+	reader := bufio.NewReader(pr)
+
+	pending := 0
+	deadline := time.Now().Add(sw.policy.interval)
+
+	flush := func() {
+		if pending == 0 {
+			return
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+		pending = 0
+		deadline = time.Now().Add(sw.policy.interval)
+	}
+
+	for {
+		line, err := reader.ReadBytes('\n')
+		if len(line) > 0 {
+			sw.w.Write(line)
+			pending++
+		}
+		if pending >= sw.policy.everyN || time.Now().After(deadline) {
+			flush()
+		}
+		if err != nil {
+			flush()
+			return
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+)
+
+// discardResponseWriter is an http.ResponseWriter/http.Flusher that throws
+// writes away, so the benchmarks below measure pool, pipe and channel
+// overhead rather than real network I/O.
+type discardResponseWriter struct {
+	header http.Header
+}
+
+func (d *discardResponseWriter) Header() http.Header         { return d.header }
+func (d *discardResponseWriter) Write(p []byte) (int, error) { return len(p), nil }
+func (d *discardResponseWriter) WriteHeader(int)             {}
+func (d *discardResponseWriter) Flush()                      {}
+
+// benchmarkConcurrentStreams runs run once per simulated stream, with
+// concurrency streams in flight at once, b.N times.
+func benchmarkConcurrentStreams(b *testing.B, concurrency int, run func(lines [][]byte)) {
+	lines := make([][]byte, 50)
+	for i := range lines {
+		lines[i] = []byte("data: {\"choices\":[{\"delta\":{\"content\":\"x\"}}]}\n")
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		var wg sync.WaitGroup
+		wg.Add(concurrency)
+		for j := 0; j < concurrency; j++ {
+			go func() {
+				defer wg.Done()
+				run(lines)
+			}()
+		}
+		wg.Wait()
+	}
+}
+
+// BenchmarkStreamWriter_Pooled measures newStreamWriter, whose writer
+// goroutine is borrowed from the shared, bounded clientWritePool, under
+// 1k concurrent simulated streams.
+func BenchmarkStreamWriter_Pooled(b *testing.B) {
+	policy := flushPolicy{interval: time.Millisecond, everyN: 8}
+	benchmarkConcurrentStreams(b, 1000, func(lines [][]byte) {
+		sw := newStreamWriter(&discardResponseWriter{header: make(http.Header)}, policy)
+		for _, line := range lines {
+			sw.Write(line)
+		}
+		sw.Close()
+	})
+}
+
+// BenchmarkStreamWriter_GoroutinePerStream measures the same workload
+// spawning one dedicated, unbounded goroutine per stream, fed through a
+// buffered channel instead of a pooled writer and pipe, for comparison
+// under the same 1k concurrent streams.
+func BenchmarkStreamWriter_GoroutinePerStream(b *testing.B) {
+	benchmarkConcurrentStreams(b, 1000, func(lines [][]byte) {
+		w := &discardResponseWriter{header: make(http.Header)}
+		ch := make(chan []byte, 32)
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			for line := range ch {
+				w.Write(line)
+			}
+		}()
+		for _, line := range lines {
+			ch <- line
+		}
+		close(ch)
+		<-done
+	})
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"gopkg.in/yaml.v3"
+)
+
+const proxyConfigPath = "config.yaml"
+
+// TenantLimits holds the per-minute request and token caps enforced for a
+// tenant.
+type TenantLimits struct {
+	RequestsPerMinute int `yaml:"requests_per_minute"`
+	TokensPerMinute   int `yaml:"tokens_per_minute"`
+}
+
+// Tenant maps an inbound proxy API key to the upstream credentials and
+// limits that apply to whoever holds it, so the proxy can serve multiple
+// callers without everyone sharing one global OPENAI_API_KEY.
+type Tenant struct {
+	Name            string       `yaml:"name"`
+	APIKey          string       `yaml:"api_key"`
+	OpenAIAPIKey    string       `yaml:"openai_api_key"`
+	AnthropicAPIKey string       `yaml:"anthropic_api_key"`
+	Limits          TenantLimits `yaml:"limits"`
+}
+
+// ProxyConfig is the multi-tenant configuration loaded from config.yaml.
+type ProxyConfig struct {
+	Tenants []Tenant `yaml:"tenants"`
+}
+
+// loadProxyConfig loads the multi-tenant configuration from path. A missing
+// file is not an error: the proxy falls back to single-tenant mode using
+// the OPENAI_API_KEY / ANTHROPIC_API_KEY from the environment.
+func loadProxyConfig(path string) (*ProxyConfig, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return &ProxyConfig{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading %s: %v", path, err)
+	}
+
+	var pc ProxyConfig
+	if err := yaml.Unmarshal(data, &pc); err != nil {
+		return nil, fmt.Errorf("error parsing %s: %v", path, err)
+	}
+	return &pc, nil
+}
+
+// tenantByAPIKey looks up the tenant that presented apiKey, if any.
+func (pc *ProxyConfig) tenantByAPIKey(apiKey string) (Tenant, bool) {
+	for _, t := range pc.Tenants {
+		if t.APIKey == apiKey {
+			return t, true
+		}
+	}
+	return Tenant{}, false
+}
+
+// effectiveConfig merges a tenant's upstream credential overrides over the
+// process-wide defaults, so providers can keep reading from a plain
+// *Config without knowing about tenants.
+func (t Tenant) effectiveConfig(defaults *Config) *Config {
+	cfg := *defaults
+	if t.OpenAIAPIKey != "" {
+		cfg.OpenAIAPIKey = t.OpenAIAPIKey
+	}
+	if t.AnthropicAPIKey != "" {
+		cfg.AnthropicAPIKey = t.AnthropicAPIKey
+	}
+	return &cfg
+}
+
+type tenantContextKey struct{}
+
+// tenantFromContext returns the tenant resolved by authMiddleware for this
+// request, or a Tenant named "default" with no credential overrides or
+// limits if auth wasn't configured.
+func tenantFromContext(ctx context.Context) Tenant {
+	if t, ok := ctx.Value(tenantContextKey{}).(Tenant); ok {
+		return t
+	}
+	return Tenant{Name: "default"}
+}
+
+func withTenant(ctx context.Context, t Tenant) context.Context {
+	return context.WithValue(ctx, tenantContextKey{}, t)
+}
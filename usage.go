@@ -0,0 +1,120 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// tokensPerChar approximates English text at roughly 4 characters per
+// token, the same rule of thumb OpenAI documents for rough estimates
+// without running an actual tokenizer.
+const charsPerToken = 4
+
+// estimateTokens approximates the token count of a string when a provider
+// doesn't report real usage. It's a heuristic, not a tokenizer: good enough
+// for consistent billing/metering fields, not for exact counts.
+func estimateTokens(text string) int {
+	if text == "" {
+		return 0
+	}
+	tokens := len(text) / charsPerToken
+	if tokens == 0 {
+		tokens = 1
+	}
+	return tokens
+}
+
+// estimatePromptTokens approximates the prompt token count of a chat
+// completion request from the concatenated content of its messages.
+func estimatePromptTokens(messages []map[string]interface{}) int {
+	var sb strings.Builder
+	for _, msg := range messages {
+		if content, ok := msg["content"].(string); ok {
+			sb.WriteString(content)
+			sb.WriteString("\n")
+		}
+	}
+	return estimateTokens(sb.String())
+}
+
+// hasRealUsage reports whether a chat completion (or chunk) JSON body
+// already carries a non-empty "usage" object.
+func hasRealUsage(body []byte) bool {
+	var resp struct {
+		Usage map[string]interface{} `json:"usage"`
+	}
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return false
+	}
+	return len(resp.Usage) > 0
+}
+
+// parseUsage extracts the prompt/completion/total token counts from an
+// OpenAI-style "usage" object, optionally prefixed with "data: ".
+func parseUsage(data []byte) (OpenAIUsage, bool) {
+	jsonData := data
+	if strings.HasPrefix(string(data), "data: ") {
+		jsonData = data[6:]
+	}
+
+	var resp struct {
+		Usage struct {
+			PromptTokens     int `json:"prompt_tokens"`
+			CompletionTokens int `json:"completion_tokens"`
+			TotalTokens      int `json:"total_tokens"`
+		} `json:"usage"`
+	}
+	if err := json.Unmarshal(jsonData, &resp); err != nil {
+		return OpenAIUsage{}, false
+	}
+	if resp.Usage.PromptTokens == 0 && resp.Usage.CompletionTokens == 0 && resp.Usage.TotalTokens == 0 {
+		return OpenAIUsage{}, false
+	}
+
+	return OpenAIUsage{
+		PromptTokens:     resp.Usage.PromptTokens,
+		CompletionTokens: resp.Usage.CompletionTokens,
+		TotalTokens:      resp.Usage.TotalTokens,
+	}, true
+}
+
+// synthesizeUsage builds a usage object from estimated prompt/completion
+// token counts for providers and error paths that report no real usage.
+func synthesizeUsage(promptTokens, completionTokens int) OpenAIUsage {
+	return OpenAIUsage{
+		PromptTokens:     promptTokens,
+		CompletionTokens: completionTokens,
+		TotalTokens:      promptTokens + completionTokens,
+	}
+}
+
+// injectEstimatedUsage sets a synthesized "usage" field on a non-streaming
+// chat completion response body, estimating prompt tokens from the request
+// and completion tokens from the response's own message content.
+func injectEstimatedUsage(body []byte, chatReq OpenAIChatCompletionRequest) ([]byte, error) {
+	var resp map[string]interface{}
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return nil, err
+	}
+
+	var completionText string
+	if choices, ok := resp["choices"].([]interface{}); ok && len(choices) > 0 {
+		if choice, ok := choices[0].(map[string]interface{}); ok {
+			if message, ok := choice["message"].(map[string]interface{}); ok {
+				completionText, _ = message["content"].(string)
+			}
+		}
+	}
+
+	promptTokens := estimatePromptTokens(chatReq.Messages)
+	usage := synthesizeUsage(promptTokens, estimateTokens(completionText))
+
+	usageMap := map[string]interface{}{
+		"prompt_tokens":     usage.PromptTokens,
+		"completion_tokens": usage.CompletionTokens,
+		"total_tokens":      usage.TotalTokens,
+	}
+	resp["usage"] = usageMap
+
+	return json.Marshal(resp)
+}